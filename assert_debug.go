@@ -0,0 +1,18 @@
+//go:build grouplock_debug
+
+package grouplock
+
+// assertUnlocked panics if lockData is returned to a shard's pool while it
+// still looks held, to catch pooling bugs during development and testing
+// without paying for the check in release builds.
+func assertUnlocked(lockData *lockItem) {
+	select {
+	case lockData.writeCh <- struct{}{}:
+		<-lockData.writeCh
+	default:
+		panic("grouplock: pooling a lockItem that is still write-locked")
+	}
+	if lockData.readers != 0 {
+		panic("grouplock: pooling a lockItem with active readers")
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !grouplock_debug
+
+package grouplock
+
+// assertUnlocked is a no-op outside of grouplock_debug builds; see
+// assert_debug.go.
+func assertUnlocked(lockData *lockItem) {}
@@ -0,0 +1,235 @@
+// Package etcdlock implements grouplock.Locker on top of etcd, so callers
+// can coordinate a key across processes instead of just goroutines in one.
+package etcdlock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// defaultPrefix is prepended to every key's etcd path when none is given.
+const defaultPrefix = "/grouplock/"
+
+// defaultTTL is the lease TTL, in seconds, a Session uses when none is given.
+const defaultTTL = 10
+
+// sessionRetryInterval is how long watch waits between attempts to
+// re-create a session after a failed attempt.
+const sessionRetryInterval = time.Second
+
+// ErrClosed is returned by Lock/TryLock/RLock once Close has been called.
+var ErrClosed = errors.New("etcdlock: locker is closed")
+
+// clock abstracts time.After so tests can drive session-retry backoff with
+// a fake clock instead of waiting on real timers.
+type clock interface {
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Option configures an EtcdLocker.
+type Option func(*EtcdLocker)
+
+// WithPrefix sets the etcd key prefix every lock's key is created under.
+// Defaults to "/grouplock/".
+func WithPrefix(prefix string) Option {
+	return func(l *EtcdLocker) {
+		if prefix != "" {
+			l.prefix = prefix
+		}
+	}
+}
+
+// WithTTL sets the lease TTL, in seconds, for the underlying etcd session.
+// A session re-created after expiry uses this TTL as well.
+func WithTTL(seconds int) Option {
+	return func(l *EtcdLocker) {
+		if seconds > 0 {
+			l.ttl = seconds
+		}
+	}
+}
+
+// EtcdLocker implements grouplock.Locker on top of a long-lived etcd
+// Session: one concurrency.Mutex (or rwMutex, for RLock) per key, keyed by
+// prefix. Cleanup is driven by lease expiry rather than the refcounting
+// GroupLock uses — if the process holding a key dies, etcd revokes the key
+// once the lease lapses. If the session itself expires or its keepalive is
+// lost, a background goroutine transparently replaces it so callers never
+// have to notice, the same way etcd-rules' SessionManager does.
+type EtcdLocker struct {
+	client *clientv3.Client
+	prefix string
+	ttl    int
+	clock  clock
+
+	newSession func() (*concurrency.Session, error)
+
+	mu      sync.Mutex
+	session *concurrency.Session
+	closed  bool
+}
+
+// New creates an EtcdLocker backed by client. It opens a Session immediately
+// so the first Lock call doesn't pay that latency, and starts a goroutine
+// that re-creates the session if it ever expires.
+func New(client *clientv3.Client, opts ...Option) (*EtcdLocker, error) {
+	l := &EtcdLocker{
+		client: client,
+		prefix: defaultPrefix,
+		ttl:    defaultTTL,
+		clock:  realClock{},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.newSession = l.createSession
+
+	session, err := l.newSession()
+	if err != nil {
+		return nil, err
+	}
+	l.session = session
+
+	go l.watch(session.Done())
+	return l, nil
+}
+
+func (l *EtcdLocker) createSession() (*concurrency.Session, error) {
+	return concurrency.NewSession(l.client, concurrency.WithTTL(l.ttl))
+}
+
+// watch waits for done (a Session's Done channel) to close and, unless the
+// EtcdLocker has been closed, replaces the session with a fresh one,
+// retrying with a fixed backoff if session creation itself fails, so
+// in-flight and future Lock/RLock calls never have to deal with a dead
+// session. It takes a channel rather than a *concurrency.Session so the
+// retry/backoff loop can be exercised in tests without a live etcd server.
+func (l *EtcdLocker) watch(done <-chan struct{}) {
+	for {
+		<-done
+
+		if l.isClosed() {
+			return
+		}
+
+		next, err := l.newSession()
+		for err != nil {
+			<-l.clock.After(sessionRetryInterval)
+			if l.isClosed() {
+				return
+			}
+			next, err = l.newSession()
+		}
+
+		l.mu.Lock()
+		if l.closed {
+			l.mu.Unlock()
+			next.Close()
+			return
+		}
+		l.session = next
+		l.mu.Unlock()
+
+		done = next.Done()
+	}
+}
+
+func (l *EtcdLocker) isClosed() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closed
+}
+
+func (l *EtcdLocker) currentSession() (*concurrency.Session, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, ErrClosed
+	}
+	return l.session, nil
+}
+
+// Close revokes the current session's lease (dropping every key still held
+// under it) and stops the background session-watcher.
+func (l *EtcdLocker) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	session := l.session
+	l.mu.Unlock()
+
+	return session.Close()
+}
+
+// Lock implements grouplock.Locker.
+func (l *EtcdLocker) Lock(ctx context.Context, key string) (release func() error, err error) {
+	session, err := l.currentSession()
+	if err != nil {
+		return nil, err
+	}
+	m := concurrency.NewMutex(session, l.prefix+key)
+	if err := m.Lock(ctx); err != nil {
+		return nil, err
+	}
+	return lockReleaser(m), nil
+}
+
+// TryLock implements grouplock.Locker.
+func (l *EtcdLocker) TryLock(ctx context.Context, key string) (release func() error, ok bool, err error) {
+	session, err := l.currentSession()
+	if err != nil {
+		return nil, false, err
+	}
+	m := concurrency.NewMutex(session, l.prefix+key)
+	if err := m.TryLock(ctx); err != nil {
+		if errors.Is(err, concurrency.ErrLocked) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return lockReleaser(m), true, nil
+}
+
+// RLock implements grouplock.Locker.
+func (l *EtcdLocker) RLock(ctx context.Context, key string) (release func() error, err error) {
+	session, err := l.currentSession()
+	if err != nil {
+		return nil, err
+	}
+	m := newRWMutex(session, l.prefix+key)
+	if err := m.rLock(ctx); err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func() error {
+		var rerr error
+		once.Do(func() { rerr = m.unlock(context.Background()) })
+		return rerr
+	}, nil
+}
+
+// lockReleaser returns an idempotent release closure for an acquired
+// concurrency.Mutex. Unlock uses a background context: by the time release
+// is called, the caller's original ctx may already be done, but the key
+// still needs to be deleted.
+func lockReleaser(m *concurrency.Mutex) func() error {
+	var once sync.Once
+	return func() error {
+		var err error
+		once.Do(func() { err = m.Unlock(context.Background()) })
+		return err
+	}
+}
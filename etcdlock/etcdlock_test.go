@@ -0,0 +1,41 @@
+package etcdlock
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/werbenhu/grouplock"
+	"github.com/werbenhu/grouplock/lockertest"
+)
+
+// TestEtcdLocker_Conformance runs the shared Locker conformance suite
+// against a real etcd cluster. It needs a live server, so it's skipped
+// unless ETCDLOCK_TEST_ENDPOINTS names one (comma-separated), e.g.:
+//
+//	ETCDLOCK_TEST_ENDPOINTS=127.0.0.1:2379 go test ./...
+func TestEtcdLocker_Conformance(t *testing.T) {
+	endpoints := os.Getenv("ETCDLOCK_TEST_ENDPOINTS")
+	if endpoints == "" {
+		t.Skip("ETCDLOCK_TEST_ENDPOINTS not set; skipping etcd-backed conformance test")
+	}
+
+	lockertest.Run(t, func(t *testing.T) (grouplock.Locker, func()) {
+		client, err := clientv3.New(clientv3.Config{Endpoints: strings.Split(endpoints, ",")})
+		if err != nil {
+			t.Fatalf("connecting to etcd: %v", err)
+		}
+
+		locker, err := New(client, WithPrefix("/grouplock-test/"), WithTTL(2))
+		if err != nil {
+			t.Fatalf("creating EtcdLocker: %v", err)
+		}
+
+		return locker, func() {
+			locker.Close()
+			client.Close()
+		}
+	})
+}
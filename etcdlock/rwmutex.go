@@ -0,0 +1,125 @@
+package etcdlock
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// readerSeq gives each rwMutex registration a call-local suffix on top of the
+// session's lease ID, so two RLock calls sharing one EtcdLocker's session
+// don't collide on the same etcd key (see register).
+var readerSeq uint64
+
+func nextReaderSeq() uint64 {
+	return atomic.AddUint64(&readerSeq, 1)
+}
+
+// kindReader tags a rwMutex reader's key so other readers scanning the same
+// prefix know to skip it. Writers go through plain concurrency.Mutex
+// instead, whose keys carry an empty value and so never match this tag —
+// meaning a reader always waits behind an older writer, the same prefix
+// EtcdLocker.Lock uses.
+const kindReader = "r"
+
+// rwMutex is a distributed read lock sharing its key prefix with the
+// concurrency.Mutex EtcdLocker.Lock/TryLock use for the same key: every
+// reader registers a key under pfx tagged kindReader, ordered by create
+// revision, and waits only for older keys that AREN'T tagged kindReader
+// (i.e. writer keys) to be deleted — so concurrent readers never block each
+// other, while a writer's plain Mutex.Lock still waits for every older key
+// regardless of tag, giving it exclusivity over both readers and writers.
+type rwMutex struct {
+	s   *concurrency.Session
+	pfx string
+
+	myKey string
+	myRev int64
+}
+
+func newRWMutex(s *concurrency.Session, pfx string) *rwMutex {
+	return &rwMutex{s: s, pfx: pfx + "/"}
+}
+
+// rLock registers this reader's key and blocks until every older key that
+// isn't itself a reader (i.e. every older writer) has been deleted.
+func (m *rwMutex) rLock(ctx context.Context) error {
+	client := m.s.Client()
+	if err := m.register(ctx); err != nil {
+		return err
+	}
+
+	for {
+		blocker, err := m.firstBlocker(ctx)
+		if err != nil {
+			m.unlock(client.Ctx())
+			return err
+		}
+		if blocker == "" {
+			return nil
+		}
+		if err := waitForDelete(ctx, client, blocker); err != nil {
+			m.unlock(client.Ctx())
+			return err
+		}
+	}
+}
+
+// register creates myKey, tagged kindReader, under pfx and records its
+// create revision. myKey is suffixed with a call-local sequence number, not
+// just the session's lease ID, because one EtcdLocker's session is shared by
+// every RLock call: keying solely on the lease would make two concurrent
+// readers for the same key collide on one physical etcd key, so the second
+// reader would silently reuse the first's entry instead of registering its
+// own (and either one's unlock would release both).
+func (m *rwMutex) register(ctx context.Context) error {
+	client := m.s.Client()
+	m.myKey = fmt.Sprintf("%s%x-%x", m.pfx, m.s.Lease(), nextReaderSeq())
+
+	cmp := clientv3.Compare(clientv3.CreateRevision(m.myKey), "=", 0)
+	put := clientv3.OpPut(m.myKey, kindReader, clientv3.WithLease(m.s.Lease()))
+	get := clientv3.OpGet(m.myKey)
+	resp, err := client.Txn(ctx).If(cmp).Then(put).Else(get).Commit()
+	if err != nil {
+		return err
+	}
+	if resp.Succeeded {
+		m.myRev = resp.Header.Revision
+	} else {
+		m.myRev = resp.Responses[0].GetResponseRange().Kvs[0].CreateRevision
+	}
+	return nil
+}
+
+// firstBlocker returns the oldest key this reader must wait on before it
+// can proceed, or "" if none remain.
+func (m *rwMutex) firstBlocker(ctx context.Context) (string, error) {
+	client := m.s.Client()
+	resp, err := client.Get(ctx, m.pfx, clientv3.WithPrefix(),
+		clientv3.WithSort(clientv3.SortByCreateRevision, clientv3.SortAscend))
+	if err != nil {
+		return "", err
+	}
+	for _, kv := range resp.Kvs {
+		if kv.CreateRevision >= m.myRev {
+			break
+		}
+		if string(kv.Value) == kindReader {
+			// Readers never block other readers.
+			continue
+		}
+		return string(kv.Key), nil
+	}
+	return "", nil
+}
+
+func (m *rwMutex) unlock(ctx context.Context) error {
+	client := m.s.Client()
+	_, err := client.Delete(ctx, m.myKey)
+	m.myKey = "\x00"
+	m.myRev = -1
+	return err
+}
@@ -0,0 +1,64 @@
+package etcdlock
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// fakeClock lets the test control exactly when watch's retry backoff fires,
+// instead of waiting on sessionRetryInterval in real time.
+type fakeClock struct {
+	fire chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{fire: make(chan time.Time, 1)}
+}
+
+func (c *fakeClock) After(time.Duration) <-chan time.Time {
+	return c.fire
+}
+
+func (c *fakeClock) advance() {
+	c.fire <- time.Time{}
+}
+
+// TestEtcdLocker_WatchRetriesSessionCreation exercises watch's retry/backoff
+// loop without a live etcd server: createSession is stubbed to fail once
+// before succeeding, and a fake clock stands in for the real retry delay so
+// the test doesn't have to sleep.
+func TestEtcdLocker_WatchRetriesSessionCreation(t *testing.T) {
+	clock := newFakeClock()
+	attempted := make(chan struct{}, 2)
+	succeeded := make(chan struct{})
+
+	l := &EtcdLocker{clock: clock}
+	var attempts int
+	l.newSession = func() (*concurrency.Session, error) {
+		attempts++
+		attempted <- struct{}{}
+		if attempts == 1 {
+			return nil, errors.New("transient etcd error")
+		}
+		close(succeeded)
+		return &concurrency.Session{}, nil
+	}
+
+	done := make(chan struct{})
+	close(done) // simulates the initial session having already ended
+	go l.watch(done)
+
+	<-attempted // first (failing) attempt
+	clock.advance()
+
+	select {
+	case <-succeeded:
+		assert.Equal(t, 2, attempts, "watch should have retried exactly once after the failure")
+	case <-time.After(time.Second):
+		t.Fatal("watch never retried session creation after the failure")
+	}
+}
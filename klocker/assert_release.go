@@ -0,0 +1,7 @@
+//go:build !klocker_debug
+
+package klocker
+
+// assertUnlocked is a no-op outside of klocker_debug builds; see
+// klocker_assert_debug.go.
+func assertUnlocked(lockData *lockItem) {}
@@ -0,0 +1,596 @@
+package klocker
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultCleanInterval is now just a safety net: most cleanup happens
+// opportunistically every defaultGCFrequency unlocks (see WithGCFrequency).
+const defaultCleanInterval = 6 * time.Hour
+
+// defaultGCFrequency is how many Unlock/RUnlock calls elapse between
+// opportunistic cleanup passes, per shard.
+const defaultGCFrequency = 1024
+
+// ErrLockTimeout is returned by LockTimeout/RLockTimeout when the lock could
+// not be acquired before the given duration elapsed.
+var ErrLockTimeout = errors.New("klocker: lock acquisition timed out")
+
+// StatsFunc reports cumulative lock-item lifecycle counters, summed across
+// all shards. It is invoked after every cleanup pass.
+type StatsFunc func(created, reused, evicted uint64)
+
+// Option defines a function type for modifying Mutex options.
+type Option func(*Mutex)
+
+// lockItem represents the lock data for each key. writeCh is a buffered
+// channel of size 1 acting as the exclusive (write) slot; readers tracks how
+// many readers currently hold it on the read side. mu guards readers, count
+// and dead: count must be checked and decided upon (evict or not) under the
+// same lock that acquire uses to bump it, or a concurrent acquire can land on
+// an item cleanupShard has already decided to delete. cond is signaled
+// whenever writeCh is drained, so a blocked RLockContext/RLockTimeout caller
+// is woken the moment it has a chance to proceed instead of polling.
+type lockItem struct {
+	mu      sync.Mutex
+	cond    sync.Cond
+	writeCh chan struct{}
+	readers int32
+	count   int32
+	dead    bool
+}
+
+func newLockItem() *lockItem {
+	item := &lockItem{writeCh: make(chan struct{}, 1)}
+	item.cond.L = &item.mu
+	return item
+}
+
+// shard holds one slice of the key space, each with its own lock table and
+// cleanup bookkeeping so unrelated keys never contend on the same sync.Map.
+type shard struct {
+	locks       sync.Map  // Map to store locks for each key in this shard
+	cleanKeys   sync.Map  // Map to track keys in this shard that need cleanup
+	pool        sync.Pool // Recycles evicted *lockItem values
+	gcFrequency uint64    // Run cleanup every gcFrequency unlocks in this shard
+	unlocks     uint64    // Atomic counter of Unlock/RUnlock calls in this shard
+	created     uint64    // Atomic counter of lock items created in this shard
+	reused      uint64    // Atomic counter of lock items reused in this shard
+	evicted     uint64    // Atomic counter of lock items evicted in this shard
+	size        int64     // Atomic count of live lock items in this shard
+}
+
+// Mutex provides locking for keys with support for automatic cleanup of unused locks.
+type Mutex struct {
+	shards   []*shard      // Power-of-two sized array of shards
+	mask     uint64        // len(shards)-1, used to route a key's hash to a shard
+	closeCh  chan struct{} // Channel for stopping the cleanup goroutine
+	interval time.Duration // Interval for the safety-net ticker
+	stats    StatsFunc     // Optional metrics hook, called after each cleanup pass
+}
+
+// WithInterval is an option that sets the safety-net cleanup interval. Most
+// cleanup now happens opportunistically; this ticker only guards against
+// workloads that stop unlocking keys before gcFrequency is reached.
+func WithInterval(interval time.Duration) Option {
+	return func(gl *Mutex) {
+		if interval > 0 {
+			gl.interval = interval
+		}
+	}
+}
+
+// WithGCFrequency is an option that sets how many Unlock/RUnlock calls on a
+// given shard elapse between opportunistic cleanup passes for that shard. A
+// value of 0 disables opportunistic cleanup, leaving only the safety-net
+// ticker.
+func WithGCFrequency(n int) Option {
+	return func(gl *Mutex) {
+		if n >= 0 {
+			for _, s := range gl.shards {
+				s.gcFrequency = uint64(n)
+			}
+		}
+	}
+}
+
+// WithStats is an option that registers a callback invoked with cumulative
+// created/reused/evicted counters, summed across all shards, after every
+// cleanup pass.
+func WithStats(fn StatsFunc) Option {
+	return func(gl *Mutex) {
+		gl.stats = fn
+	}
+}
+
+// WithShards is an option that sets the number of shards the key space is
+// split across. n is rounded up to the next power of two. Defaults to
+// runtime.GOMAXPROCS(0) rounded up to a power of two.
+func WithShards(n int) Option {
+	return func(gl *Mutex) {
+		if n > 0 {
+			gl.shards = newShards(nextPowerOfTwo(n))
+			gl.mask = uint64(len(gl.shards) - 1)
+		}
+	}
+}
+
+// nextPowerOfTwo returns the smallest power of two greater than or equal to n.
+func nextPowerOfTwo(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+func newShards(n int) []*shard {
+	shards := make([]*shard, n)
+	for i := range shards {
+		shards[i] = &shard{gcFrequency: defaultGCFrequency}
+	}
+	return shards
+}
+
+// fnv1a hashes key with the 32-bit FNV-1a algorithm, used to route keys to
+// shards without allocating.
+func fnv1a(key string) uint32 {
+	const offsetBasis uint32 = 2166136261
+	const prime uint32 = 16777619
+
+	h := offsetBasis
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= prime
+	}
+	return h
+}
+
+// shardFor returns the shard responsible for key.
+func (gl *Mutex) shardFor(key string) *shard {
+	return gl.shards[uint64(fnv1a(key))&gl.mask]
+}
+
+// New creates a new Mutex with provided options.
+// It starts a background goroutine that runs cleanup as a safety net, in
+// addition to the opportunistic cleanup driven by unlock cadence.
+func New(opts ...Option) *Mutex {
+	gl := &Mutex{
+		closeCh:  make(chan struct{}),
+		interval: defaultCleanInterval, // Default safety-net interval
+	}
+
+	n := nextPowerOfTwo(runtime.GOMAXPROCS(0))
+	gl.shards = newShards(n)
+	gl.mask = uint64(n - 1)
+
+	// Apply options to the Mutex
+	for _, opt := range opts {
+		opt(gl)
+	}
+
+	// Start the cleaner goroutine
+	go gl.cleaner()
+
+	return gl
+}
+
+// acquire returns the lock item for key, creating and registering one in its
+// shard if it doesn't exist yet, and bumps its reference count. Candidates
+// come from the shard's pool when possible, so a key churning through
+// lock/unlock cycles doesn't force a fresh allocation each time.
+//
+// The count bump happens under lockData.mu, the same lock cleanupShard holds
+// while deciding whether to evict. That's what stops a concurrent Lock from
+// landing on an item cleanupShard has already committed to deleting: if
+// LoadOrStore hands back an item cleanupShard marked dead, acquire retries
+// instead of reusing it.
+//
+// A freshly created candidate is bumped *before* LoadOrStore publishes it,
+// not after: candidate isn't reachable from the shard yet, so nothing else
+// can observe or touch it, and publishing it with count already at 1 means
+// cleanupShard can never find it sitting at a stale zero in the window
+// between registration and the caller actually using it.
+func (gl *Mutex) acquire(s *shard, key string) *lockItem {
+	for {
+		candidate, ok := s.pool.Get().(*lockItem)
+		if !ok {
+			candidate = newLockItem()
+		}
+		candidate.mu.Lock()
+		candidate.count++
+		candidate.mu.Unlock()
+
+		item, loaded := s.locks.LoadOrStore(key, candidate)
+		if !loaded {
+			atomic.AddUint64(&s.created, 1)
+			atomic.AddInt64(&s.size, 1)
+			return candidate
+		}
+
+		// Lost the race to register candidate; undo the bump and hand it
+		// back to the pool unused.
+		candidate.mu.Lock()
+		candidate.count--
+		candidate.mu.Unlock()
+		s.pool.Put(candidate)
+
+		lockData := item.(*lockItem)
+		lockData.mu.Lock()
+		if lockData.dead {
+			// cleanupShard already decided to evict this item and is
+			// deleting it from the map; go around and register a fresh one.
+			lockData.mu.Unlock()
+			continue
+		}
+		lockData.count++
+		lockData.mu.Unlock()
+		atomic.AddUint64(&s.reused, 1)
+		return lockData
+	}
+}
+
+// release decrements the reference count for key within its shard. If no
+// references remain, the key is marked for cleanup. Every gcFrequency calls
+// on the shard also trigger an inline cleanup pass so its lock table shrinks
+// between ticker runs.
+func (gl *Mutex) release(s *shard, key string, lockData *lockItem) {
+	lockData.mu.Lock()
+	lockData.count--
+	needsCleanup := lockData.count <= 0
+	lockData.mu.Unlock()
+
+	if needsCleanup {
+		s.cleanKeys.Store(key, struct{}{})
+	}
+
+	if s.gcFrequency > 0 && atomic.AddUint64(&s.unlocks, 1)%s.gcFrequency == 0 {
+		gl.cleanupShard(s)
+	}
+}
+
+// Len reports the number of keys currently tracked across all shards,
+// including ones pending cleanup.
+func (gl *Mutex) Len() int {
+	var n int64
+	for _, s := range gl.shards {
+		n += atomic.LoadInt64(&s.size)
+	}
+	return int(n)
+}
+
+// Lock acquires an exclusive lock for the given key. It increments the
+// reference count and blocks until the write slot is free.
+func (gl *Mutex) Lock(key string) {
+	s := gl.shardFor(key)
+	lockData := gl.acquire(s, key)
+	lockData.writeCh <- struct{}{}
+}
+
+// Unlock releases the exclusive lock held for the given key. It decrements
+// the reference count.
+func (gl *Mutex) Unlock(key string) {
+	s := gl.shardFor(key)
+	if item, ok := s.locks.Load(key); ok {
+		lockData := item.(*lockItem)
+		<-lockData.writeCh
+		gl.signalRelease(lockData)
+		gl.release(s, key, lockData)
+	}
+}
+
+// signalRelease wakes any goroutine parked in rLockWait once the write slot
+// has just been freed, so a blocked RLockContext/RLockTimeout caller notices
+// immediately instead of on its next poll.
+func (gl *Mutex) signalRelease(lockData *lockItem) {
+	lockData.mu.Lock()
+	lockData.cond.Broadcast()
+	lockData.mu.Unlock()
+}
+
+// TryLock attempts to acquire an exclusive lock for the given key without
+// blocking. It reports whether the lock was acquired.
+func (gl *Mutex) TryLock(key string) bool {
+	s := gl.shardFor(key)
+	lockData := gl.acquire(s, key)
+	select {
+	case lockData.writeCh <- struct{}{}:
+		return true
+	default:
+		gl.release(s, key, lockData)
+		return false
+	}
+}
+
+// LockContext acquires an exclusive lock for the given key, blocking until
+// it succeeds or ctx is done, whichever happens first.
+func (gl *Mutex) LockContext(ctx context.Context, key string) error {
+	s := gl.shardFor(key)
+	lockData := gl.acquire(s, key)
+	select {
+	case lockData.writeCh <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		gl.release(s, key, lockData)
+		return ctx.Err()
+	}
+}
+
+// LockTimeout acquires an exclusive lock for the given key, blocking until
+// it succeeds or the given duration elapses, whichever happens first.
+func (gl *Mutex) LockTimeout(key string, d time.Duration) error {
+	s := gl.shardFor(key)
+	lockData := gl.acquire(s, key)
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case lockData.writeCh <- struct{}{}:
+		return nil
+	case <-timer.C:
+		gl.release(s, key, lockData)
+		return ErrLockTimeout
+	}
+}
+
+// LockFunc acquires an exclusive lock for the given key and returns a closure
+// that releases it. The closure is idempotent, so `defer unlock()` is safe
+// even if the caller also calls it explicitly — unlike Unlock(key), it can't
+// be called with the wrong key, called twice, or forgotten.
+func (gl *Mutex) LockFunc(key string) (unlock func()) {
+	s := gl.shardFor(key)
+	lockData := gl.acquire(s, key)
+	lockData.writeCh <- struct{}{}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-lockData.writeCh
+			gl.signalRelease(lockData)
+			gl.release(s, key, lockData)
+		})
+	}
+}
+
+// rLock registers lockData as read-held, acquiring the write slot on behalf
+// of the first concurrent reader so writers wait for every reader to finish.
+func (gl *Mutex) rLock(lockData *lockItem) {
+	lockData.mu.Lock()
+	if lockData.readers == 0 {
+		lockData.writeCh <- struct{}{}
+	}
+	lockData.readers++
+	lockData.mu.Unlock()
+}
+
+// tryRLock is the non-blocking counterpart of rLock.
+func (gl *Mutex) tryRLock(lockData *lockItem) bool {
+	lockData.mu.Lock()
+	defer lockData.mu.Unlock()
+
+	if lockData.readers == 0 {
+		select {
+		case lockData.writeCh <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	lockData.readers++
+	return true
+}
+
+// rUnlock releases lockData's read hold, releasing the write slot once the
+// last reader is done.
+func (gl *Mutex) rUnlock(lockData *lockItem) {
+	lockData.mu.Lock()
+	lockData.readers--
+	if lockData.readers == 0 {
+		<-lockData.writeCh
+		lockData.cond.Broadcast()
+	}
+	lockData.mu.Unlock()
+}
+
+// RLock acquires a shared read lock for the given key. Multiple readers of
+// the same key may proceed concurrently; a writer still waits for all of
+// them to finish. It increments the reference count.
+func (gl *Mutex) RLock(key string) {
+	s := gl.shardFor(key)
+	lockData := gl.acquire(s, key)
+	gl.rLock(lockData)
+}
+
+// RUnlock releases the shared read lock held for the given key. It
+// decrements the reference count.
+func (gl *Mutex) RUnlock(key string) {
+	s := gl.shardFor(key)
+	if item, ok := s.locks.Load(key); ok {
+		lockData := item.(*lockItem)
+		gl.rUnlock(lockData)
+		gl.release(s, key, lockData)
+	}
+}
+
+// RLockFunc acquires a shared read lock for the given key and returns a
+// closure that releases it. The closure is idempotent, so `defer runlock()`
+// is safe even if the caller also calls it explicitly.
+func (gl *Mutex) RLockFunc(key string) (runlock func()) {
+	s := gl.shardFor(key)
+	lockData := gl.acquire(s, key)
+	gl.rLock(lockData)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			gl.rUnlock(lockData)
+			gl.release(s, key, lockData)
+		})
+	}
+}
+
+// TryRLock attempts to acquire a shared read lock for the given key without
+// blocking. It reports whether the lock was acquired.
+func (gl *Mutex) TryRLock(key string) bool {
+	s := gl.shardFor(key)
+	lockData := gl.acquire(s, key)
+	if gl.tryRLock(lockData) {
+		return true
+	}
+	gl.release(s, key, lockData)
+	return false
+}
+
+// RLockContext acquires a shared read lock for the given key, blocking
+// until it succeeds or ctx is done, whichever happens first.
+func (gl *Mutex) RLockContext(ctx context.Context, key string) error {
+	s := gl.shardFor(key)
+	lockData := gl.acquire(s, key)
+
+	if err := gl.rLockWait(ctx, lockData); err != nil {
+		gl.release(s, key, lockData)
+		return err
+	}
+	return nil
+}
+
+// rLockWait blocks until lockData's write slot can be taken on behalf of a
+// reader (or is already held by readers), or ctx is done, whichever happens
+// first. It waits on lockData.cond instead of polling tryRLock, so a reader
+// notices a writer's release as soon as Unlock/rUnlock broadcasts it.
+func (gl *Mutex) rLockWait(ctx context.Context, lockData *lockItem) error {
+	stopWatch := make(chan struct{})
+	defer close(stopWatch)
+	go func() {
+		select {
+		case <-ctx.Done():
+			gl.signalRelease(lockData)
+		case <-stopWatch:
+		}
+	}()
+
+	lockData.mu.Lock()
+	defer lockData.mu.Unlock()
+	for {
+		if lockData.readers > 0 {
+			lockData.readers++
+			return nil
+		}
+		select {
+		case lockData.writeCh <- struct{}{}:
+			lockData.readers++
+			return nil
+		default:
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		lockData.cond.Wait()
+	}
+}
+
+// RLockTimeout acquires a shared read lock for the given key, blocking
+// until it succeeds or the given duration elapses, whichever happens first.
+func (gl *Mutex) RLockTimeout(key string, d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	if err := gl.RLockContext(ctx, key); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return ErrLockTimeout
+		}
+		return err
+	}
+	return nil
+}
+
+// cleaner is a background goroutine that periodically runs cleanup tasks,
+// visiting one shard per tick in round-robin order so a single interval
+// never has to sweep the whole key space at once.
+func (gl *Mutex) cleaner() {
+	ticker := time.NewTicker(gl.interval)
+	defer ticker.Stop()
+
+	idx := 0
+	for {
+		select {
+		case <-ticker.C:
+			gl.cleanupShard(gl.shards[idx])
+			idx = (idx + 1) % len(gl.shards)
+		case <-gl.closeCh:
+			return
+		}
+	}
+}
+
+// cleanupShard removes the locks that are no longer in use within a single
+// shard.
+func (gl *Mutex) cleanupShard(s *shard) {
+	var keysToRemove []string
+
+	// Check each key marked for cleanup
+	s.cleanKeys.Range(func(key, _ interface{}) bool {
+		k := key.(string)
+
+		// If the lock is no longer in use, delete it. The count check and
+		// the map delete happen under lockData.mu, the same lock acquire
+		// bumps count under, so a concurrent acquire can never observe a
+		// count of zero and then have its item vanish out from under it.
+		if item, ok := s.locks.Load(k); ok {
+			lockData := item.(*lockItem)
+
+			lockData.mu.Lock()
+			evict := lockData.count <= 0
+			if evict {
+				lockData.dead = true
+				s.locks.Delete(k)
+			}
+			lockData.mu.Unlock()
+
+			if evict {
+				keysToRemove = append(keysToRemove, k)
+
+				assertUnlocked(lockData)
+				lockData.mu.Lock()
+				lockData.count = 0
+				lockData.dead = false
+				lockData.mu.Unlock()
+				s.pool.Put(lockData)
+			}
+		}
+
+		return true
+	})
+
+	// Clean up the keys from the clean-up tracking map
+	for _, key := range keysToRemove {
+		s.cleanKeys.Delete(key)
+	}
+
+	if n := len(keysToRemove); n > 0 {
+		atomic.AddUint64(&s.evicted, uint64(n))
+		atomic.AddInt64(&s.size, -int64(n))
+	}
+
+	if gl.stats != nil {
+		var created, reused, evicted uint64
+		for _, sh := range gl.shards {
+			created += atomic.LoadUint64(&sh.created)
+			reused += atomic.LoadUint64(&sh.reused)
+			evicted += atomic.LoadUint64(&sh.evicted)
+		}
+		gl.stats(created, reused, evicted)
+	}
+}
+
+// Stop stops the cleaner goroutine.
+func (gl *Mutex) Stop() {
+	close(gl.closeCh)
+}
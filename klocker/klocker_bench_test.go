@@ -23,8 +23,8 @@ func sharedLock(wg *sync.WaitGroup) {
 	sharedMutex.Unlock()
 }
 
-// groupLockTest simulates locking and unlocking keys using KLocker.
-func kLockerTest(kl *KLocker, keys []string, wg *sync.WaitGroup) {
+// mutexTest simulates locking and unlocking keys using Mutex.
+func mutexTest(kl *Mutex, keys []string, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	// Randomly lock one of the keys
@@ -58,8 +58,8 @@ func BenchmarkSharedMutex(b *testing.B) {
 	}
 }
 
-// BenchmarkKeyLocker tests the performance of KLocker approach.
-func BenchmarkKeyLocker(b *testing.B) {
+// BenchmarkMutex tests the performance of the Mutex approach.
+func BenchmarkMutex(b *testing.B) {
 	goroutineCount := 1000
 	keys := make([]string, numberOfKeys)
 	kl := New()
@@ -76,7 +76,7 @@ func BenchmarkKeyLocker(b *testing.B) {
 		// Start goroutines
 		for k := 0; k < goroutineCount; k++ {
 			wg.Add(1)
-			go kLockerTest(kl, keys, &wg)
+			go mutexTest(kl, keys, &wg)
 		}
 		wg.Wait()
 	}
@@ -0,0 +1,522 @@
+package klocker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyLocker_LockUnlock(t *testing.T) {
+	// Initialize KeyLocker
+	kl := New()
+
+	// Test Lock and Unlock for a single key
+	key := "user1"
+
+	// Lock the key
+	kl.Lock(key)
+
+	// Unlock the key
+	kl.Unlock(key)
+
+	// Ensure the lock can be used again after unlocking (test with a new lock)
+	kl.Lock(key)
+
+	// Unlock again
+	kl.Unlock(key)
+
+	// Clean up
+	kl.Stop()
+}
+
+func TestKeyLocker_LockMultipleUsers(t *testing.T) {
+	// Initialize KeyLocker
+	kl := New()
+
+	// Define multiple user keys
+	keys := []string{"user1", "user2", "user3"}
+
+	// Lock each key in parallel
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		go func(k string) {
+			defer wg.Done()
+			kl.Lock(k)
+			time.Sleep(100 * time.Millisecond)
+			kl.Unlock(k)
+		}(key)
+	}
+
+	// Wait for all goroutines to finish
+	wg.Wait()
+
+	// Ensure all locks were released successfully
+	for _, key := range keys {
+		kl.Lock(key) // Should not block
+		kl.Unlock(key)
+	}
+
+	// Clean up
+	kl.Stop()
+}
+
+func TestKeyLocker_AutomaticCleanup(t *testing.T) {
+	// Initialize KeyLocker with cleanup interval of 1 second for testing
+	kl := New(WithInterval(1*time.Second), WithShards(1))
+
+	// Lock some keys
+	keys := []string{"user1", "user2", "user3"}
+	for _, key := range keys {
+		kl.Lock(key)
+	}
+
+	// Sleep for 2 seconds to let the cleaner run
+	time.Sleep(2 * time.Second)
+
+	// Verify that the locks are cleaned up after being unlocked
+	for _, key := range keys {
+		kl.Unlock(key)
+	}
+
+	// Sleep to ensure cleanup happens
+	time.Sleep(2 * time.Second)
+
+	// Verify that all keys are cleaned up
+	for _, s := range kl.shards {
+		s.locks.Range(func(key, value interface{}) bool {
+			t.Errorf("Key %v still exists in the lock map", key)
+			return true
+		})
+	}
+
+	// Clean up
+	kl.Stop()
+}
+
+func TestKeyLocker_LockCleanupAfterUnlock(t *testing.T) {
+	// Initialize KeyLocker with a short cleanup interval
+	kl := New(WithInterval(1*time.Second), WithShards(1))
+
+	// Lock a key
+	key := "user1"
+	kl.Lock(key)
+
+	// Unlock the key
+	kl.Unlock(key)
+
+	// Sleep for 2 seconds to allow the cleaner to run
+	time.Sleep(2 * time.Second)
+
+	// Verify that the lock is cleaned up after the unlock
+	_, loaded := kl.shardFor(key).locks.Load(key)
+	assert.False(t, loaded, "Lock for key %s should be cleaned up", key)
+
+	// Clean up
+	kl.Stop()
+}
+
+func TestKeyLocker_StopCleaner(t *testing.T) {
+	// Initialize KeyLocker
+	kl := New(WithInterval(1 * time.Second))
+
+	// Lock a key
+	key := "user1"
+	kl.Lock(key)
+
+	// Stop the cleaner
+	kl.Stop()
+
+	// Unlock the key after stopping the cleaner
+	kl.Unlock(key)
+
+	// Verify that the lock was unlocked
+	_, loaded := kl.shardFor(key).locks.Load(key)
+	assert.True(t, loaded, "Lock for key %s should exist after unlock", key)
+}
+
+func TestKeyLocker_RLockConcurrentReaders(t *testing.T) {
+	// Initialize KeyLocker
+	kl := New()
+
+	key := "user1"
+	var active int32
+	var maxActive int32
+	var wg sync.WaitGroup
+
+	// Multiple readers on the same key should run concurrently
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			kl.RLock(key)
+			n := atomic.AddInt32(&active, 1)
+			for {
+				max := atomic.LoadInt32(&maxActive)
+				if n <= max || atomic.CompareAndSwapInt32(&maxActive, max, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			atomic.AddInt32(&active, -1)
+			kl.RUnlock(key)
+		}()
+	}
+
+	wg.Wait()
+	assert.Greater(t, maxActive, int32(1), "readers should have overlapped")
+
+	// Clean up
+	kl.Stop()
+}
+
+func TestKeyLocker_RLockExcludesWriter(t *testing.T) {
+	// Initialize KeyLocker
+	kl := New()
+
+	key := "user1"
+	kl.RLock(key)
+
+	locked := make(chan struct{})
+	go func() {
+		kl.Lock(key)
+		close(locked)
+		kl.Unlock(key)
+	}()
+
+	// The writer must wait while the reader holds the lock
+	select {
+	case <-locked:
+		t.Fatal("writer should not acquire the lock while a reader holds it")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	kl.RUnlock(key)
+	<-locked
+
+	// Clean up
+	kl.Stop()
+}
+
+func TestKeyLocker_TryLockContention(t *testing.T) {
+	// Initialize KeyLocker
+	kl := New()
+
+	key := "user1"
+	kl.Lock(key)
+
+	// A concurrent TryLock must fail while the key is held
+	assert.False(t, kl.TryLock(key), "TryLock should fail while the key is locked")
+
+	kl.Unlock(key)
+
+	// Once released, TryLock should succeed
+	assert.True(t, kl.TryLock(key), "TryLock should succeed once the key is free")
+	kl.Unlock(key)
+
+	// Clean up
+	kl.Stop()
+}
+
+func TestKeyLocker_TryLockNeverLeaksOnFailure(t *testing.T) {
+	// Initialize KeyLocker with a fast cleanup interval
+	kl := New(WithInterval(1*time.Second), WithShards(1))
+
+	key := "user1"
+	kl.Lock(key)
+	assert.False(t, kl.TryLock(key))
+	kl.Unlock(key)
+
+	// Sleep to allow the cleaner to run
+	time.Sleep(2 * time.Second)
+
+	// The key must have been cleaned up; the failed TryLock should not have
+	// left behind a dangling reference.
+	_, loaded := kl.shardFor(key).locks.Load(key)
+	assert.False(t, loaded, "lock item should be cleaned up after the only holder unlocked")
+
+	kl.Stop()
+}
+
+func TestKeyLocker_LockContextCancellation(t *testing.T) {
+	// Initialize KeyLocker
+	kl := New()
+
+	key := "user1"
+	kl.Lock(key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := kl.LockContext(ctx, key)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	kl.Unlock(key)
+
+	// Clean up
+	kl.Stop()
+}
+
+func TestKeyLocker_LockTimeout(t *testing.T) {
+	// Initialize KeyLocker
+	kl := New()
+
+	key := "user1"
+	kl.Lock(key)
+
+	err := kl.LockTimeout(key, 50*time.Millisecond)
+	assert.ErrorIs(t, err, ErrLockTimeout)
+
+	kl.Unlock(key)
+
+	// LockTimeout should succeed once the key is free
+	assert.NoError(t, kl.LockTimeout(key, 50*time.Millisecond))
+	kl.Unlock(key)
+
+	// Clean up
+	kl.Stop()
+}
+
+func TestKeyLocker_OpportunisticGC(t *testing.T) {
+	// A very long ticker interval means only the unlock-driven GC can
+	// possibly clean up the keys below.
+	kl := New(WithShards(1), WithInterval(time.Hour), WithGCFrequency(4))
+
+	keys := []string{"user1", "user2", "user3", "user4"}
+	for _, key := range keys {
+		kl.Lock(key)
+	}
+	assert.Equal(t, 4, kl.Len())
+
+	// The 4th unlock should trigger an inline cleanup pass.
+	for _, key := range keys {
+		kl.Unlock(key)
+	}
+
+	assert.Equal(t, 0, kl.Len())
+	for _, key := range keys {
+		_, loaded := kl.shardFor(key).locks.Load(key)
+		assert.False(t, loaded, "key %s should have been cleaned up opportunistically", key)
+	}
+
+	kl.Stop()
+}
+
+func TestKeyLocker_WithStats(t *testing.T) {
+	var created, reused, evicted uint64
+	var mu sync.Mutex
+
+	kl := New(WithGCFrequency(2), WithStats(func(c, r, e uint64) {
+		mu.Lock()
+		created, reused, evicted = c, r, e
+		mu.Unlock()
+	}))
+
+	key := "user1"
+	kl.Lock(key)
+	kl.Unlock(key)
+	kl.Lock(key)
+	kl.Unlock(key)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, uint64(1), created)
+	assert.Equal(t, uint64(1), reused)
+	assert.Equal(t, uint64(1), evicted)
+
+	kl.Stop()
+}
+
+func TestKeyLocker_LockFuncIdempotent(t *testing.T) {
+	kl := New(WithShards(1))
+
+	key := "user1"
+	unlock := kl.LockFunc(key)
+
+	// A concurrent Lock must wait until unlock() runs.
+	assert.False(t, kl.TryLock(key))
+
+	unlock()
+	unlock() // calling it again must be a no-op, not a double-release
+
+	// The key should be free for exactly one more lock.
+	assert.True(t, kl.TryLock(key))
+	kl.Unlock(key)
+
+	kl.Stop()
+}
+
+func TestKeyLocker_LockFuncDeferIsSafe(t *testing.T) {
+	kl := New(WithShards(1))
+
+	key := "user1"
+	func() {
+		unlock := kl.LockFunc(key)
+		defer unlock()
+		unlock() // explicit call before the deferred one
+	}()
+
+	assert.True(t, kl.TryLock(key))
+	kl.Unlock(key)
+
+	kl.Stop()
+}
+
+func TestKeyLocker_RLockFuncIdempotent(t *testing.T) {
+	kl := New(WithShards(1))
+
+	key := "user1"
+	runlock := kl.RLockFunc(key)
+
+	// A concurrent writer must wait until runlock() runs.
+	assert.False(t, kl.TryLock(key))
+
+	runlock()
+	runlock() // calling it again must be a no-op
+
+	assert.True(t, kl.TryLock(key))
+	kl.Unlock(key)
+
+	kl.Stop()
+}
+
+func TestKeyLocker_WithShardsRoundsUpToPowerOfTwo(t *testing.T) {
+	kl := New(WithShards(5))
+	assert.Equal(t, 8, len(kl.shards))
+	kl.Stop()
+}
+
+func TestKeyLocker_ShardingRoutesIndependentKeys(t *testing.T) {
+	// With multiple shards, locking distinct keys whose hashes land on
+	// different shards must not contend on the same underlying map.
+	kl := New(WithShards(4))
+
+	keys := []string{"user1", "user2", "user3", "user4"}
+	for _, key := range keys {
+		kl.Lock(key)
+	}
+	for _, key := range keys {
+		kl.Unlock(key)
+	}
+
+	kl.Stop()
+}
+
+func TestKeyLocker_PoolReusesEvictedLockItems(t *testing.T) {
+	// A short GC frequency forces eviction after each key's only lock is
+	// released, so the next key to land on the same shard should reuse the
+	// evicted *lockItem instead of allocating a new one.
+	kl := New(WithShards(1), WithGCFrequency(1))
+
+	kl.Lock("user1")
+	kl.Unlock("user1")
+
+	// The pool should now hold the lockItem evicted for "user1".
+	s := kl.shardFor("user2")
+	pooled, ok := s.pool.Get().(*lockItem)
+	if assert.True(t, ok, "evicted lockItem should have been returned to the pool") {
+		assert.Equal(t, int32(0), pooled.count)
+		s.pool.Put(pooled)
+	}
+
+	// Locking a different key on the same shard must still work correctly
+	// after drawing from the pool.
+	kl.Lock("user2")
+	kl.Unlock("user2")
+
+	kl.Stop()
+}
+
+func TestKeyLocker_MultipleLocksOnSameKey(t *testing.T) {
+	// Initialize KeyLocker
+	kl := New(WithInterval(1*time.Second), WithShards(1))
+
+	// Lock the same key in two different goroutines
+	key := "user1"
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+
+	// First goroutine to lock the key
+	go func() {
+		defer wg.Done()
+		kl.Lock(key)
+		defer kl.Unlock(key)
+	}()
+
+	// Second goroutine to lock the key
+	go func() {
+		defer wg.Done()
+		kl.Lock(key)
+		defer kl.Unlock(key)
+	}()
+
+	// Wait for both goroutines to finish
+	wg.Wait()
+
+	// Sleep for 2 seconds to allow the cleaner to run
+	time.Sleep(2 * time.Second)
+
+	// Ensure the lock is released after both unlocks
+	// Check that the lock item is cleaned up after both unlocks
+	_, loaded := kl.shardFor(key).locks.Load(key)
+	assert.False(t, loaded, "Lock for key %s should be cleaned up after both unlocks", key)
+
+	// Clean up
+	kl.Stop()
+}
+
+// TestKeyLocker_ConcurrentLockUnlockWithAggressiveGC is a regression test for
+// a race between cleanupShard and acquire: cleanupShard used to decide a key
+// was idle and delete it from the map in a separate step from the count
+// check, so a concurrent Lock for that same key could land on the item right
+// as it was being evicted and pooled. Run with -race, and with
+// -tags grouplock_debug to also exercise assertUnlocked.
+func TestKeyLocker_ConcurrentLockUnlockWithAggressiveGC(t *testing.T) {
+	kl := New(WithGCFrequency(1))
+	defer kl.Stop()
+
+	keys := []string{"a", "b", "c"}
+	held := make([]int32, len(keys))
+	var violated int32
+
+	const goroutines = 64
+	const itersPerGoroutine = 200
+
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for g := 0; g < goroutines; g++ {
+		go func(seed int) {
+			defer wg.Done()
+			for i := 0; i < itersPerGoroutine; i++ {
+				idx := (seed + i) % len(keys)
+				key := keys[idx]
+
+				kl.Lock(key)
+				if !atomic.CompareAndSwapInt32(&held[idx], 0, 1) {
+					atomic.StoreInt32(&violated, 1)
+				}
+				atomic.StoreInt32(&held[idx], 0)
+				kl.Unlock(key)
+			}
+		}(g)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("deadlock: goroutines never finished locking/unlocking under aggressive GC")
+	}
+
+	assert.Zero(t, atomic.LoadInt32(&violated), "two goroutines held the same key's lock at once")
+}
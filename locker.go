@@ -0,0 +1,78 @@
+package grouplock
+
+import (
+	"context"
+	"sync"
+)
+
+// Locker is the interface a pluggable lock backend must satisfy so callers
+// can coordinate across goroutines in one process (MemLocker, backed by a
+// GroupLock) or across processes (see the etcdlock subpackage, backed by
+// etcd). Every acquire call returns a release closure instead of requiring
+// the caller to remember the key, the same convention LockFunc/RLockFunc
+// use below.
+type Locker interface {
+	// Lock blocks until key is exclusively acquired or ctx is done.
+	Lock(ctx context.Context, key string) (release func() error, err error)
+
+	// TryLock attempts to acquire key without blocking. ok reports whether
+	// the lock was acquired; release is nil when ok is false.
+	TryLock(ctx context.Context, key string) (release func() error, ok bool, err error)
+
+	// RLock blocks until a shared lock on key is acquired or ctx is done.
+	RLock(ctx context.Context, key string) (release func() error, err error)
+}
+
+// MemLocker adapts a *GroupLock to the Locker interface. It exists because
+// GroupLock's own Lock/TryLock/RLock methods predate Locker and already use
+// incompatible signatures (no ctx, no error return); MemLocker translates
+// Locker's calls onto GroupLock's existing context-aware methods instead of
+// changing its public API.
+type MemLocker struct {
+	gl *GroupLock
+}
+
+// NewMemLocker wraps gl so it can be used anywhere a Locker is expected.
+func NewMemLocker(gl *GroupLock) *MemLocker {
+	return &MemLocker{gl: gl}
+}
+
+// Lock implements Locker. Acquisition still goes through LockContext so ctx
+// cancellation is honored (LockFunc has no ctx parameter), but once acquired
+// the release closure is built the same idempotent way LockFunc's is, so
+// double-releasing it can't double-unlock the key.
+func (m *MemLocker) Lock(ctx context.Context, key string) (release func() error, err error) {
+	if err := m.gl.LockContext(ctx, key); err != nil {
+		return nil, err
+	}
+	var once sync.Once
+	return func() error {
+		once.Do(func() { m.gl.Unlock(key) })
+		return nil
+	}, nil
+}
+
+// TryLock implements Locker.
+func (m *MemLocker) TryLock(ctx context.Context, key string) (release func() error, ok bool, err error) {
+	if !m.gl.TryLock(key) {
+		return nil, false, nil
+	}
+	var once sync.Once
+	return func() error {
+		once.Do(func() { m.gl.Unlock(key) })
+		return nil
+	}, true, nil
+}
+
+// RLock implements Locker. See Lock for why RLockContext+sync.Once is used
+// instead of RLockFunc directly.
+func (m *MemLocker) RLock(ctx context.Context, key string) (release func() error, err error) {
+	if err := m.gl.RLockContext(ctx, key); err != nil {
+		return nil, err
+	}
+	var once sync.Once
+	return func() error {
+		once.Do(func() { m.gl.RUnlock(key) })
+		return nil
+	}, nil
+}
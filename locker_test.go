@@ -0,0 +1,38 @@
+package grouplock_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/werbenhu/grouplock"
+	"github.com/werbenhu/grouplock/lockertest"
+)
+
+func TestMemLocker_Conformance(t *testing.T) {
+	lockertest.Run(t, func(t *testing.T) (grouplock.Locker, func()) {
+		gl := grouplock.New()
+		return grouplock.NewMemLocker(gl), gl.Stop
+	})
+}
+
+func TestMemLocker_ReleaseIsIdempotent(t *testing.T) {
+	gl := grouplock.New()
+	defer gl.Stop()
+	locker := grouplock.NewMemLocker(gl)
+
+	ctx := context.Background()
+	release, err := locker.Lock(ctx, "key")
+	assert.NoError(t, err)
+
+	// Calling release() twice must not double-unlock the key.
+	assert.NoError(t, release())
+	assert.NoError(t, release())
+
+	// The key should be free after a single logical release.
+	r2, ok, err := locker.TryLock(ctx, "key")
+	assert.NoError(t, err)
+	if assert.True(t, ok, "key should be free after release()") {
+		assert.NoError(t, r2())
+	}
+}
@@ -0,0 +1,132 @@
+// Package lockertest provides a conformance suite every grouplock.Locker
+// implementation must pass, so new backends (e.g. etcdlock) can be checked
+// against the same baseline behaviour as the in-memory one.
+package lockertest
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/werbenhu/grouplock"
+)
+
+// Factory builds a fresh Locker for a single subtest and a teardown func the
+// subtest will defer.
+type Factory func(t *testing.T) (locker grouplock.Locker, teardown func())
+
+// Run exercises newLocker against the baseline behaviour every Locker
+// implementation must provide: exclusive Lock/TryLock semantics, shared
+// RLock semantics, and clean release.
+func Run(t *testing.T, newLocker Factory) {
+	t.Run("LockExcludesLock", func(t *testing.T) { testLockExcludesLock(t, newLocker) })
+	t.Run("TryLockFailsWhileHeld", func(t *testing.T) { testTryLockFailsWhileHeld(t, newLocker) })
+	t.Run("RLockAllowsConcurrentReaders", func(t *testing.T) { testRLockAllowsConcurrentReaders(t, newLocker) })
+	t.Run("RLockExcludesWriter", func(t *testing.T) { testRLockExcludesWriter(t, newLocker) })
+}
+
+func testLockExcludesLock(t *testing.T, newLocker Factory) {
+	locker, teardown := newLocker(t)
+	defer teardown()
+
+	ctx := context.Background()
+	release, err := locker.Lock(ctx, "key")
+	assert.NoError(t, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		r, err := locker.Lock(ctx, "key")
+		assert.NoError(t, err)
+		close(acquired)
+		assert.NoError(t, r())
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second Lock should not succeed while the first is held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.NoError(t, release())
+	<-acquired
+}
+
+func testTryLockFailsWhileHeld(t *testing.T, newLocker Factory) {
+	locker, teardown := newLocker(t)
+	defer teardown()
+
+	ctx := context.Background()
+	release, err := locker.Lock(ctx, "key")
+	assert.NoError(t, err)
+
+	_, ok, err := locker.TryLock(ctx, "key")
+	assert.NoError(t, err)
+	assert.False(t, ok, "TryLock should fail while the key is locked")
+
+	assert.NoError(t, release())
+
+	r2, ok, err := locker.TryLock(ctx, "key")
+	assert.NoError(t, err)
+	if assert.True(t, ok, "TryLock should succeed once the key is free") {
+		assert.NoError(t, r2())
+	}
+}
+
+func testRLockAllowsConcurrentReaders(t *testing.T, newLocker Factory) {
+	locker, teardown := newLocker(t)
+	defer teardown()
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	var active, maxActive int32
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := locker.RLock(ctx, "key")
+			assert.NoError(t, err)
+
+			n := atomic.AddInt32(&active, 1)
+			for {
+				max := atomic.LoadInt32(&maxActive)
+				if n <= max || atomic.CompareAndSwapInt32(&maxActive, max, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			atomic.AddInt32(&active, -1)
+			assert.NoError(t, release())
+		}()
+	}
+	wg.Wait()
+	assert.Greater(t, maxActive, int32(1), "readers should have overlapped")
+}
+
+func testRLockExcludesWriter(t *testing.T, newLocker Factory) {
+	locker, teardown := newLocker(t)
+	defer teardown()
+
+	ctx := context.Background()
+	runlock, err := locker.RLock(ctx, "key")
+	assert.NoError(t, err)
+
+	locked := make(chan struct{})
+	go func() {
+		release, err := locker.Lock(ctx, "key")
+		assert.NoError(t, err)
+		close(locked)
+		assert.NoError(t, release())
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("writer should not acquire the lock while a reader holds it")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.NoError(t, runlock())
+	<-locked
+}